@@ -0,0 +1,87 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/Bren2010/utahfs/persistent"
+
+	"github.com/robfig/cron/v3"
+)
+
+// startBackups starts a background goroutine that periodically snapshots
+// src to the configured backup destination, per c.Backup's schedule. The WAL
+// is drained before each snapshot so the backup is point-in-time consistent.
+func (c *Client) startBackups(src persistent.ObjectStorage, wal *persistent.LocalWAL, pinPath string) error {
+	if c.Backup.Schedule == "" {
+		return fmt.Errorf("backup schedule is required")
+	} else if c.Backup.Destination == nil {
+		return fmt.Errorf("backup destination is required")
+	}
+	if c.Backup.Retention == 0 {
+		c.Backup.Retention = 7
+	}
+
+	dst, err := c.Backup.Destination.Store()
+	if err != nil {
+		return fmt.Errorf("backup destination: %v", err)
+	}
+
+	// Snapshot/Prune need to enumerate both sides' keys; fail now, with a
+	// clear config error, rather than on the first cron tick.
+	if _, ok := src.(persistent.Lister); !ok {
+		return fmt.Errorf("backups require a storage-provider that supports listing its keys")
+	} else if _, ok := dst.(persistent.Lister); !ok {
+		return fmt.Errorf("backups require a backup destination that supports listing its keys")
+	}
+
+	sched, err := cron.ParseStandard(c.Backup.Schedule)
+	if err != nil {
+		return fmt.Errorf("backup schedule: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.backupCancel = cancel
+	go runBackups(ctx, src, dst, wal, pinPath, sched, c.Backup.Retention)
+
+	return nil
+}
+
+func runBackups(ctx context.Context, src, dst persistent.ObjectStorage, wal *persistent.LocalWAL, pinPath string, sched cron.Schedule, retention int) {
+	for {
+		next := sched.Next(time.Now())
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := backupOnce(ctx, src, dst, wal, pinPath, retention); err != nil {
+			log.Printf("utahfs: backup failed: %v", err)
+		}
+	}
+}
+
+func backupOnce(ctx context.Context, src, dst persistent.ObjectStorage, wal *persistent.LocalWAL, pinPath string, retention int) error {
+	if err := wal.Drain(ctx); err != nil {
+		return fmt.Errorf("draining wal: %v", err)
+	}
+	pin, err := ioutil.ReadFile(pinPath)
+	if err != nil {
+		return fmt.Errorf("reading pin file: %v", err)
+	}
+
+	gen, err := persistent.Snapshot(ctx, src, dst, pin, time.Now())
+	if err != nil {
+		return fmt.Errorf("snapshotting: %v", err)
+	}
+	log.Printf("utahfs: wrote backup generation %s", gen)
+
+	return persistent.Prune(ctx, dst, retention)
+}
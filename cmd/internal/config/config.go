@@ -1,8 +1,11 @@
 package config
 
 import (
+	"context"
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path"
 
 	"github.com/Bren2010/utahfs"
@@ -18,10 +21,35 @@ type Client struct {
 	MaxWALSize      int              `yaml:"max-wal-size"` // Max number of blocks to put in WAL before blocking on remote storage. Default: 64*512 blocks
 
 	CacheSize int    `yaml:"cache-size"` // Size of in-memory LRU cache. Default: 32*1024 blocks, -1 to disable.
-	Password  string `yaml:"password"`   // Password for encryption and integrity. Mandatory.
+	Password  string `yaml:"password"`   // Password for integrity, and for encryption if key-provider is unset. Mandatory.
+
+	// KeyProvider optionally switches encryption to the envelope scheme,
+	// where the filesystem's data-encryption key is generated once, wrapped
+	// under this provider, and stored in keyfile.json. e.g.
+	// "kms://aws/<key-arn>", "kms://gcp/<resource-name>", "kms://vault/<key-name>",
+	// or "passphrase://" to wrap it with a key derived from Password instead
+	// of a KMS. Leave unset to derive the encryption key directly from
+	// Password, as before key-provider support existed.
+	KeyProvider string `yaml:"key-provider"`
+
+	DiskCacheSize int64  `yaml:"disk-cache-size"` // Size in bytes of the on-disk LRU cache. Default: 0, disabled.
+	DiskCacheDir  string `yaml:"disk-cache-dir"`  // Directory to store on-disk cache blocks in. Default: <data-dir>/disk-cache
 
 	NumPtrs  int64 `yaml:"num-ptrs"`  // Number of pointers in a file's skiplist. Default: 12
 	DataSize int64 `yaml:"data-size"` // Amount of data kept in each of a file's blocks. Default: 32 KiB
+
+	Backup *Backup `yaml:"backup"` // Optional schedule for encrypted snapshots to a secondary storage provider.
+
+	diskCache    *persistent.DiskCache // Set by FS, if a disk cache tier was configured. Used by Close.
+	backupCancel context.CancelFunc    // Set by FS, if scheduled backups were configured. Used by Close.
+}
+
+// Backup configures periodic, consistent snapshots of the object store to a
+// secondary StorageProvider, for disaster recovery.
+type Backup struct {
+	Schedule    string           `yaml:"schedule"`    // Cron expression, e.g. "@daily". Mandatory.
+	Destination *StorageProvider `yaml:"destination"` // Where backup generations are written. Mandatory.
+	Retention   int              `yaml:"retention"`   // Number of backup generations to keep. Default: 7
 }
 
 func ClientFromFile(path string) (*Client, error) {
@@ -56,7 +84,31 @@ func (c *Client) FS(mountPath string) (*utahfs.BlockFilesystem, error) {
 		return nil, err
 	}
 
-	// Setup caching if desired.
+	// Setup scheduled backups if desired.
+	if c.Backup != nil {
+		wal, ok := relStore.(*persistent.LocalWAL)
+		if !ok {
+			return nil, fmt.Errorf("backups require a local WAL")
+		}
+		if err := c.startBackups(store, wal, path.Join(c.DataDir, "pin.json")); err != nil {
+			return nil, err
+		}
+	}
+
+	// Setup the on-disk cache tier if desired.
+	if c.DiskCacheSize > 0 {
+		if c.DiskCacheDir == "" {
+			c.DiskCacheDir = path.Join(c.DataDir, "disk-cache")
+		}
+		diskCache, err := persistent.NewDiskCache(relStore, c.DiskCacheDir, c.DiskCacheSize)
+		if err != nil {
+			return nil, err
+		}
+		c.diskCache = diskCache
+		relStore = diskCache
+	}
+
+	// Setup the in-memory cache tier if desired.
 	if c.CacheSize == 0 {
 		c.CacheSize = 32 * 1024
 	}
@@ -73,15 +125,38 @@ func (c *Client) FS(mountPath string) (*utahfs.BlockFilesystem, error) {
 
 	// Setup encryption and integrity.
 	if c.Password == "" {
-		return nil, fmt.Errorf("no password given for encryption")
+		return nil, fmt.Errorf("no password given for integrity")
 	}
 	block, err = persistent.WithIntegrity(block, c.Password, path.Join(c.DataDir, "pin.json"))
 	if err != nil {
 		return nil, err
 	}
-	block, err = persistent.WithEncryption(block, c.Password)
-	if err != nil {
-		return nil, err
+
+	if c.KeyProvider == "" {
+		// No KMS configured: preserve the original behavior exactly, so
+		// filesystems created before key-provider support remain
+		// decryptable without any migration step.
+		block, err = persistent.WithEncryption(block, c.Password)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// A KeyProvider is configured: the DEK it unwraps is already
+		// high-entropy key material, so it's passed to WithEncryption (whose
+		// signature and key-derivation we leave untouched) in place of a
+		// human password, base64-encoded since WithEncryption takes a string.
+		kp, err := persistent.NewKeyProvider(c.KeyProvider, c.Password)
+		if err != nil {
+			return nil, err
+		}
+		dek, err := persistent.LoadDEK(kp, path.Join(c.DataDir, "keyfile.json"))
+		if err != nil {
+			return nil, err
+		}
+		block, err = persistent.WithEncryption(block, base64.StdEncoding.EncodeToString(dek))
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Setup application storage.
@@ -102,7 +177,28 @@ func (c *Client) FS(mountPath string) (*utahfs.BlockFilesystem, error) {
 	return bfs, nil
 }
 
+// Close releases resources acquired by FS: it stops the scheduled-backup
+// goroutine, if any, and persists the on-disk cache's index so it can be
+// reloaded on the next start instead of leaking its block files forever. It
+// should be called as the filesystem is unmounted.
+func (c *Client) Close() error {
+	if c.backupCancel != nil {
+		c.backupCancel()
+	}
+	if c.diskCache == nil {
+		return nil
+	}
+	return c.diskCache.Close()
+}
+
 type StorageProvider struct {
+	// Url is a single connection-string describing the storage backend to
+	// use, e.g. "s3://key:secret@host/bucket?region=us-east-1" or
+	// "file:///var/lib/utahfs". It takes precedence over the per-provider
+	// fields below, and may also be set with the UTAHFS_STORAGE_URL
+	// environment variable.
+	Url string `yaml:"url"`
+
 	B2AcctId string `yaml:"b2-acct-id"`
 	B2AppKey string `yaml:"b2-app-key"`
 	B2Bucket string `yaml:"b2-bucket"`
@@ -114,6 +210,11 @@ type StorageProvider struct {
 	S3Url    string `yaml:"s3-url"`
 	S3Region string `yaml:"s3-region"`
 
+	GCSCredentialsFile string `yaml:"gcs-credentials-file"`
+	GCSBucket          string `yaml:"gcs-bucket"`
+	GCSProject         string `yaml:"gcs-project"`
+	GCSEndpoint        string `yaml:"gcs-endpoint"` // Alternate API endpoint. Used for testing against fake-gcs-server.
+
 	Retry int `yaml:"retry"` // Max number of times to retry reqs that fail.
 }
 
@@ -125,25 +226,54 @@ func (sp *StorageProvider) hasS3() bool {
 	return sp.S3AppId != "" || sp.S3AppKey != "" || sp.S3Bucket != "" || sp.S3Url != "" || sp.S3Region != ""
 }
 
+func (sp *StorageProvider) hasGCS() bool {
+	return sp.GCSCredentialsFile != "" || sp.GCSBucket != "" || sp.GCSProject != "" || sp.GCSEndpoint != ""
+}
+
 func (sp *StorageProvider) Store() (persistent.ObjectStorage, error) {
-	if sp == nil || !sp.hasB2() && !sp.hasS3() {
+	if sp == nil {
 		return nil, fmt.Errorf("no object storage provider defined")
-	} else if sp.hasB2() && sp.hasS3() {
-		return nil, fmt.Errorf("only one object storage provider may be defined")
 	}
 
-	// Connect to either B2 or S3.
 	var (
 		out persistent.ObjectStorage
 		err error
 	)
-	if sp.hasB2() {
-		out, err = persistent.NewB2(sp.B2AcctId, sp.B2AppKey, sp.B2Bucket, sp.B2Url)
-	} else if sp.hasS3() {
-		out, err = persistent.NewS3(sp.S3AppId, sp.S3AppKey, sp.S3Bucket, sp.S3Url, sp.S3Region)
-	}
-	if err != nil {
-		return nil, err
+
+	// A connection-string url, whether from config or the environment,
+	// takes precedence over the legacy per-provider fields.
+	if rawurl := sp.Url; rawurl != "" || os.Getenv("UTAHFS_STORAGE_URL") != "" {
+		if rawurl == "" {
+			rawurl = os.Getenv("UTAHFS_STORAGE_URL")
+		}
+		out, err = persistent.OpenBackendURL(rawurl)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		numProviders := 0
+		for _, has := range []bool{sp.hasB2(), sp.hasS3(), sp.hasGCS()} {
+			if has {
+				numProviders++
+			}
+		}
+		if numProviders == 0 {
+			return nil, fmt.Errorf("no object storage provider defined")
+		} else if numProviders > 1 {
+			return nil, fmt.Errorf("only one object storage provider may be defined")
+		}
+
+		// Connect to B2, S3, or GCS.
+		if sp.hasB2() {
+			out, err = persistent.NewB2(sp.B2AcctId, sp.B2AppKey, sp.B2Bucket, sp.B2Url)
+		} else if sp.hasS3() {
+			out, err = persistent.NewS3(sp.S3AppId, sp.S3AppKey, sp.S3Bucket, sp.S3Url, sp.S3Region)
+		} else if sp.hasGCS() {
+			out, err = persistent.NewGCS(sp.GCSCredentialsFile, sp.GCSBucket, sp.GCSProject, sp.GCSEndpoint)
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Configure retries if the user wants.
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/Bren2010/utahfs/persistent"
+)
+
+// RotateKEK re-wraps the filesystem's data-encryption key under a new
+// key-encryption key, without rewriting any data. newKeyProvider is a
+// connection string in the same format as the key-provider config field.
+// It backs the `rotate-kek` CLI subcommand.
+//
+// RotateKEK requires that c.KeyProvider already be set: there's no keyfile
+// to rotate for a filesystem that still derives its key directly from a
+// password, and migrating such a filesystem onto a key-provider isn't
+// supported by this command.
+func (c *Client) RotateKEK(mountPath, newKeyProvider string) error {
+	if c.KeyProvider == "" {
+		return fmt.Errorf("rotate-kek requires key-provider to already be configured")
+	}
+	if c.DataDir == "" {
+		c.DataDir = path.Join(path.Dir(mountPath), ".utahfs")
+	}
+
+	oldKP, err := persistent.NewKeyProvider(c.KeyProvider, c.Password)
+	if err != nil {
+		return err
+	}
+	newKP, err := persistent.NewKeyProvider(newKeyProvider, c.Password)
+	if err != nil {
+		return err
+	}
+
+	return persistent.RotateKEK(oldKP, newKP, path.Join(c.DataDir, "keyfile.json"))
+}
@@ -0,0 +1,25 @@
+package persistent
+
+import (
+	"net/url"
+	"strings"
+)
+
+func init() {
+	RegisterBackend(b2Factory{})
+}
+
+// b2Factory implements BackendFactory for `b2://` connection strings, e.g.
+// `b2://acctId:appKey@endpoint/bucket`.
+type b2Factory struct{}
+
+func (b2Factory) Scheme() string { return "b2" }
+
+func (b2Factory) Open(u *url.URL) (ObjectStorage, error) {
+	acctId := u.User.Username()
+	appKey, _ := u.User.Password()
+	endpoint := u.Host
+	bucket := strings.TrimPrefix(u.Path, "/")
+
+	return NewB2(acctId, appKey, bucket, endpoint)
+}
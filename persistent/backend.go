@@ -0,0 +1,44 @@
+package persistent
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// BackendFactory constructs an ObjectStorage backend from a parsed
+// connection-string URL, such as `s3://key:secret@host/bucket?region=...`.
+// Implementations register themselves with RegisterBackend at init time so
+// that adding a new backend is a single-file change.
+type BackendFactory interface {
+	// Scheme returns the URL scheme this factory handles, e.g. "s3".
+	Scheme() string
+	// Open constructs an ObjectStorage backend from the parsed URL.
+	Open(u *url.URL) (ObjectStorage, error)
+}
+
+var backends = map[string]BackendFactory{}
+
+// RegisterBackend registers a BackendFactory under its scheme. It panics if
+// the scheme is already registered, which would indicate a conflicting
+// import rather than a runtime error.
+func RegisterBackend(f BackendFactory) {
+	scheme := f.Scheme()
+	if _, ok := backends[scheme]; ok {
+		panic(fmt.Sprintf("persistent: backend already registered for scheme %q", scheme))
+	}
+	backends[scheme] = f
+}
+
+// OpenBackendURL parses rawurl and dispatches to the BackendFactory
+// registered for its scheme.
+func OpenBackendURL(rawurl string) (ObjectStorage, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("parsing storage url: %v", err)
+	}
+	f, ok := backends[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("no storage backend registered for scheme %q", u.Scheme)
+	}
+	return f.Open(u)
+}
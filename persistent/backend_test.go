@@ -0,0 +1,92 @@
+package persistent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+)
+
+// testEchoBackend is a BackendFactory registered under a scheme that's
+// reserved for this test, to avoid colliding with the schemes backends
+// register for themselves at init (gs, file, b2, s3).
+type testEchoBackend struct {
+	scheme string
+	opened *url.URL
+}
+
+func (b *testEchoBackend) Scheme() string { return b.scheme }
+
+func (b *testEchoBackend) Open(u *url.URL) (ObjectStorage, error) {
+	b.opened = u
+	return nil, nil
+}
+
+func TestOpenBackendURLDispatchesByScheme(t *testing.T) {
+	backend := &testEchoBackend{scheme: "test-dispatch"}
+	RegisterBackend(backend)
+	defer delete(backends, backend.scheme)
+
+	if _, err := OpenBackendURL("test-dispatch://bucket/path?region=us-east-1"); err != nil {
+		t.Fatalf("OpenBackendURL: %v", err)
+	}
+	if backend.opened == nil {
+		t.Fatalf("expected registered factory's Open to be called")
+	}
+	if backend.opened.Host != "bucket" {
+		t.Fatalf("expected host %q, got %q", "bucket", backend.opened.Host)
+	}
+	if got := backend.opened.Query().Get("region"); got != "us-east-1" {
+		t.Fatalf("expected region query param %q, got %q", "us-east-1", got)
+	}
+}
+
+func TestOpenBackendURLUnknownScheme(t *testing.T) {
+	if _, err := OpenBackendURL("not-a-registered-scheme://bucket"); err == nil {
+		t.Fatalf("expected an error for an unregistered scheme")
+	}
+}
+
+func TestOpenBackendURLInvalidURL(t *testing.T) {
+	if _, err := OpenBackendURL("://not a url"); err == nil {
+		t.Fatalf("expected an error for an unparseable url")
+	}
+}
+
+func TestRegisterBackendPanicsOnDuplicateScheme(t *testing.T) {
+	scheme := "test-duplicate"
+	RegisterBackend(&testEchoBackend{scheme: scheme})
+	defer delete(backends, scheme)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected RegisterBackend to panic on a duplicate scheme")
+		}
+	}()
+	RegisterBackend(&testEchoBackend{scheme: scheme})
+}
+
+func TestPreregisteredBackendSchemes(t *testing.T) {
+	for _, scheme := range []string{"gs", "file", "b2", "s3"} {
+		if _, ok := backends[scheme]; !ok {
+			t.Errorf("expected scheme %q to be registered by its backend's init()", scheme)
+		}
+	}
+}
+
+func TestLocalFactoryOpensDirFromPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backend-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := OpenBackendURL(fmt.Sprintf("file://%s", dir))
+	if err != nil {
+		t.Fatalf("OpenBackendURL: %v", err)
+	}
+	if store == nil {
+		t.Fatalf("expected a non-nil ObjectStorage")
+	}
+}
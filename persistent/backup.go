@@ -0,0 +1,88 @@
+package persistent
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Lister is implemented by ObjectStorage backends that can enumerate the
+// keys they hold, which is required in order to back them up. Backends that
+// don't implement Lister can still be used as a snapshot destination.
+type Lister interface {
+	List(ctx context.Context) ([]string, error)
+}
+
+// PinFileName is the name of the integrity pin file that's snapshotted
+// alongside every backup generation so a restore can be verified against it.
+const PinFileName = "pin.json"
+
+// Snapshot copies every object in src to dst, under a generation prefix
+// derived from when, along with the integrity pin file's contents so the
+// generation can later be verified. It returns the generation's key prefix.
+func Snapshot(ctx context.Context, src ObjectStorage, dst ObjectStorage, pin []byte, when time.Time) (string, error) {
+	lister, ok := src.(Lister)
+	if !ok {
+		return "", fmt.Errorf("persistent: backup source does not support listing its keys")
+	}
+	keys, err := lister.List(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	gen := when.UTC().Format("20060102T150405Z")
+	for _, key := range keys {
+		data, err := src.Get(ctx, key)
+		if err != nil {
+			return "", fmt.Errorf("backing up %q: %v", key, err)
+		} else if err := dst.Set(ctx, gen+"/"+key, data); err != nil {
+			return "", fmt.Errorf("backing up %q: %v", key, err)
+		}
+	}
+	if err := dst.Set(ctx, gen+"/"+PinFileName, pin); err != nil {
+		return "", fmt.Errorf("backing up pin file: %v", err)
+	}
+
+	return gen, nil
+}
+
+// Prune removes all but the `retention` most recent backup generations from
+// dst, identified by the generation prefixes that Snapshot produces.
+func Prune(ctx context.Context, dst ObjectStorage, retention int) error {
+	lister, ok := dst.(Lister)
+	if !ok {
+		return fmt.Errorf("persistent: backup destination does not support listing its keys")
+	}
+	keys, err := lister.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	gens := make(map[string]bool)
+	for _, key := range keys {
+		if idx := strings.IndexByte(key, '/'); idx != -1 {
+			gens[key[:idx]] = true
+		}
+	}
+	sorted := make([]string, 0, len(gens))
+	for gen := range gens {
+		sorted = append(sorted, gen)
+	}
+	sort.Strings(sorted) // Generation prefixes are timestamps, so this is chronological.
+
+	if len(sorted) <= retention {
+		return nil
+	}
+	for _, gen := range sorted[:len(sorted)-retention] {
+		for _, key := range keys {
+			if strings.HasPrefix(key, gen+"/") {
+				if err := dst.Delete(ctx, key); err != nil {
+					return fmt.Errorf("pruning %q: %v", key, err)
+				}
+			}
+		}
+	}
+	return nil
+}
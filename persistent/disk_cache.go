@@ -0,0 +1,215 @@
+package persistent
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DiskCache wraps a ReliableStorage and adds a disk-backed LRU block cache
+// in front of it, for working sets too large to fit in the in-memory cache
+// but still much faster to read from local disk than from remote storage.
+type DiskCache struct {
+	inner ReliableStorage
+
+	dir      string
+	maxBytes int64
+
+	mu        sync.Mutex
+	usedBytes int64
+	order     *list.List               // Front is most-recently-used.
+	elems     map[string]*list.Element // key -> element in order, value is *diskCacheEntry.
+}
+
+type diskCacheEntry struct {
+	key  string
+	size int64
+	sum  string // sha256 of the block's contents, checked on every read.
+}
+
+// NewDiskCache returns a DiskCache that stores up to maxBytes of ciphertext
+// blocks under dir, evicting the least-recently-used blocks once that's
+// exceeded. The index of what's cached is persisted to dir on Close and
+// reloaded the next time NewDiskCache is called against the same dir.
+func NewDiskCache(inner ReliableStorage, dir string, maxBytes int64) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	dc := &DiskCache{
+		inner:    inner,
+		dir:      dir,
+		maxBytes: maxBytes,
+		order:    list.New(),
+		elems:    make(map[string]*list.Element),
+	}
+	if err := dc.loadIndex(); err != nil {
+		return nil, err
+	}
+	return dc, nil
+}
+
+func (dc *DiskCache) indexPath() string { return filepath.Join(dc.dir, "index.json") }
+
+func (dc *DiskCache) blockPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	hexSum := hex.EncodeToString(sum[:])
+	return filepath.Join(dc.dir, hexSum[:2], hexSum[2:])
+}
+
+// diskCacheIndexEntry is the on-disk representation of one cached block,
+// ordered most-recently-used first.
+type diskCacheIndexEntry struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+	Sum  string `json:"sum"`
+}
+
+func (dc *DiskCache) loadIndex() error {
+	raw, err := ioutil.ReadFile(dc.indexPath())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var entries []diskCacheIndexEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, err := os.Stat(dc.blockPath(entry.Key)); err != nil {
+			continue // Block went missing out from under the index; drop it.
+		}
+		elem := dc.order.PushBack(&diskCacheEntry{key: entry.Key, size: entry.Size, sum: entry.Sum})
+		dc.elems[entry.Key] = elem
+		dc.usedBytes += entry.Size
+	}
+	return nil
+}
+
+// Close persists the cache's index to disk so it can be reloaded on the next
+// start. It does not close the inner ReliableStorage.
+func (dc *DiskCache) Close() error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	entries := make([]diskCacheIndexEntry, 0, dc.order.Len())
+	for e := dc.order.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*diskCacheEntry)
+		entries = append(entries, diskCacheIndexEntry{Key: entry.key, Size: entry.size, Sum: entry.sum})
+	}
+	raw, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dc.indexPath(), raw, 0600)
+}
+
+func (dc *DiskCache) touch(key string, size int64, sum string) {
+	if elem, ok := dc.elems[key]; ok {
+		entry := elem.Value.(*diskCacheEntry)
+		dc.usedBytes += size - entry.size
+		entry.size, entry.sum = size, sum
+		dc.order.MoveToFront(elem)
+		return
+	}
+	elem := dc.order.PushFront(&diskCacheEntry{key: key, size: size, sum: sum})
+	dc.elems[key] = elem
+	dc.usedBytes += size
+
+	for dc.usedBytes > dc.maxBytes {
+		back := dc.order.Back()
+		if back == nil {
+			break
+		}
+		victim := back.Value.(*diskCacheEntry)
+		dc.order.Remove(back)
+		delete(dc.elems, victim.key)
+		dc.usedBytes -= victim.size
+		os.Remove(dc.blockPath(victim.key))
+	}
+}
+
+func (dc *DiskCache) Get(ctx context.Context, key string) ([]byte, error) {
+	dc.mu.Lock()
+	elem, cached := dc.elems[key]
+	var wantSum string
+	if cached {
+		wantSum = elem.Value.(*diskCacheEntry).sum
+	}
+	dc.mu.Unlock()
+
+	if cached {
+		data, err := ioutil.ReadFile(dc.blockPath(key))
+		if err == nil && checksum(data) == wantSum {
+			dc.mu.Lock()
+			dc.touch(key, int64(len(data)), wantSum)
+			dc.mu.Unlock()
+			return data, nil
+		}
+		// Missing, corrupted, or unreadable (disk I/O error, bad
+		// permissions, ...): fall through and re-fetch an authoritative
+		// copy from the inner storage. A local cache-tier problem must
+		// never make otherwise-healthy remote data unreachable.
+	}
+
+	data, err := dc.inner.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	dc.store(key, data)
+	return data, nil
+}
+
+func (dc *DiskCache) Set(ctx context.Context, key string, data []byte) error {
+	if err := dc.inner.Set(ctx, key, data); err != nil {
+		return err
+	}
+	dc.store(key, data)
+	return nil
+}
+
+func (dc *DiskCache) Delete(ctx context.Context, key string) error {
+	if err := dc.inner.Delete(ctx, key); err != nil {
+		return err
+	}
+
+	dc.mu.Lock()
+	if elem, ok := dc.elems[key]; ok {
+		entry := elem.Value.(*diskCacheEntry)
+		dc.order.Remove(elem)
+		delete(dc.elems, key)
+		dc.usedBytes -= entry.size
+	}
+	dc.mu.Unlock()
+
+	os.Remove(dc.blockPath(key))
+	return nil
+}
+
+func (dc *DiskCache) store(key string, data []byte) {
+	path := dc.blockPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return
+	}
+
+	dc.mu.Lock()
+	dc.touch(key, int64(len(data)), checksum(data))
+	dc.mu.Unlock()
+}
+
+// checksum returns a hex-encoded sha256 digest of data, used to detect
+// silent corruption of blocks cached on disk.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,180 @@
+package persistent
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeReliableStorage is an in-memory ReliableStorage used to test DiskCache
+// without needing a real remote backend.
+type fakeReliableStorage struct {
+	data map[string][]byte
+	gets int
+	sets int
+	dels int
+}
+
+func newFakeReliableStorage() *fakeReliableStorage {
+	return &fakeReliableStorage{data: make(map[string][]byte)}
+}
+
+func (f *fakeReliableStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	f.gets++
+	data, ok := f.data[key]
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	return data, nil
+}
+
+func (f *fakeReliableStorage) Set(ctx context.Context, key string, data []byte) error {
+	f.sets++
+	f.data[key] = append([]byte{}, data...)
+	return nil
+}
+
+func (f *fakeReliableStorage) Delete(ctx context.Context, key string) error {
+	f.dels++
+	delete(f.data, key)
+	return nil
+}
+
+func tempDiskCacheDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "disk-cache-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestDiskCacheGetHitsCacheNotInner(t *testing.T) {
+	ctx := context.Background()
+	inner := newFakeReliableStorage()
+	inner.data["a"] = []byte("hello")
+
+	dc, err := NewDiskCache(inner, tempDiskCacheDir(t), 1<<20)
+	if err != nil {
+		t.Fatalf("NewDiskCache: %v", err)
+	}
+
+	if _, err := dc.Get(ctx, "a"); err != nil {
+		t.Fatalf("first Get: %v", err)
+	}
+	if inner.gets != 1 {
+		t.Fatalf("expected 1 inner Get after cold read, got %d", inner.gets)
+	}
+
+	if _, err := dc.Get(ctx, "a"); err != nil {
+		t.Fatalf("second Get: %v", err)
+	}
+	if inner.gets != 1 {
+		t.Fatalf("expected cached read to avoid inner Get, got %d total inner gets", inner.gets)
+	}
+}
+
+func TestDiskCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	inner := newFakeReliableStorage()
+	inner.data["a"] = []byte("1111") // 4 bytes each.
+	inner.data["b"] = []byte("2222")
+	inner.data["c"] = []byte("3333")
+
+	// Only enough room for two blocks.
+	dc, err := NewDiskCache(inner, tempDiskCacheDir(t), 8)
+	if err != nil {
+		t.Fatalf("NewDiskCache: %v", err)
+	}
+
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := dc.Get(ctx, key); err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+	}
+
+	// "a" should have been evicted in favor of "b" and "c".
+	if _, ok := dc.elems["a"]; ok {
+		t.Fatalf("expected %q to be evicted", "a")
+	}
+	if _, ok := dc.elems["b"]; !ok {
+		t.Fatalf("expected %q to still be cached", "b")
+	}
+	if _, ok := dc.elems["c"]; !ok {
+		t.Fatalf("expected %q to still be cached", "c")
+	}
+	if _, err := os.Stat(dc.blockPath("a")); !os.IsNotExist(err) {
+		t.Fatalf("expected evicted block file for %q to be removed, stat err: %v", "a", err)
+	}
+}
+
+func TestDiskCacheIndexSurvivesCloseAndReload(t *testing.T) {
+	ctx := context.Background()
+	inner := newFakeReliableStorage()
+	inner.data["a"] = []byte("hello")
+	dir := tempDiskCacheDir(t)
+
+	dc, err := NewDiskCache(inner, dir, 1<<20)
+	if err != nil {
+		t.Fatalf("NewDiskCache: %v", err)
+	}
+	if _, err := dc.Get(ctx, "a"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := dc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reloaded, err := NewDiskCache(inner, dir, 1<<20)
+	if err != nil {
+		t.Fatalf("NewDiskCache (reload): %v", err)
+	}
+	if _, err := reloaded.Get(ctx, "a"); err != nil {
+		t.Fatalf("Get after reload: %v", err)
+	}
+	if inner.gets != 1 {
+		t.Fatalf("expected reloaded index to serve from disk without hitting inner, got %d inner gets", inner.gets)
+	}
+}
+
+func TestDiskCacheFallsThroughToInnerOnLocalReadError(t *testing.T) {
+	ctx := context.Background()
+	inner := newFakeReliableStorage()
+	inner.data["a"] = []byte("hello")
+
+	dc, err := NewDiskCache(inner, tempDiskCacheDir(t), 1<<20)
+	if err != nil {
+		t.Fatalf("NewDiskCache: %v", err)
+	}
+	if _, err := dc.Get(ctx, "a"); err != nil {
+		t.Fatalf("first Get: %v", err)
+	}
+
+	// Corrupt the cached block on disk without updating the index.
+	if err := ioutil.WriteFile(dc.blockPath("a"), []byte("corrupted"), 0600); err != nil {
+		t.Fatalf("corrupting block: %v", err)
+	}
+
+	data, err := dc.Get(ctx, "a")
+	if err != nil {
+		t.Fatalf("Get after corruption should fall through to inner, got error: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("expected authoritative data %q, got %q", "hello", data)
+	}
+	if inner.gets != 2 {
+		t.Fatalf("expected corrupted read to re-fetch from inner, got %d inner gets", inner.gets)
+	}
+}
+
+func TestDiskCacheBlockPathIsSharded(t *testing.T) {
+	dc := &DiskCache{dir: "/tmp/doesnotmatter"}
+	p := dc.blockPath("some-key")
+	dir := filepath.Dir(p)
+	if filepath.Base(dir) == "doesnotmatter" {
+		t.Fatalf("expected blockPath to shard into a subdirectory, got %q", p)
+	}
+}
@@ -0,0 +1,119 @@
+package persistent
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+func init() {
+	RegisterBackend(gcsFactory{})
+}
+
+// GCS implements the ObjectStorage interface by storing data in a Google
+// Cloud Storage bucket.
+type GCS struct {
+	client  *storage.Client
+	bucket  string
+	project string // Billing project, for requester-pays buckets. Optional.
+}
+
+// NewGCS returns a GCS-backed ObjectStorage. credentialsFile is the path to
+// a service account JSON key, bucket is the name of the bucket to store
+// objects in, and endpoint is an optional alternate API endpoint, used to
+// point at a local fake-gcs-server instance during testing.
+func NewGCS(credentialsFile, bucket, project, endpoint string) (ObjectStorage, error) {
+	ctx := context.Background()
+
+	opts := []option.ClientOption{}
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+	if endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint))
+	}
+	if project != "" {
+		// Bill API usage (and, for requester-pays buckets, storage access)
+		// to the given project rather than whatever the credentials default to.
+		opts = append(opts, option.WithQuotaProject(project))
+	}
+
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GCS{client: client, bucket: bucket, project: project}, nil
+}
+
+func (g *GCS) object(key string) *storage.ObjectHandle {
+	return g.bucketHandle().Object(key)
+}
+
+func (g *GCS) bucketHandle() *storage.BucketHandle {
+	bkt := g.client.Bucket(g.bucket)
+	if g.project != "" {
+		bkt = bkt.UserProject(g.project)
+	}
+	return bkt
+}
+
+func (g *GCS) Get(ctx context.Context, key string) ([]byte, error) {
+	r, err := g.object(key).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, ErrObjectNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
+
+func (g *GCS) Set(ctx context.Context, key string, data []byte) error {
+	w := g.object(key).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (g *GCS) Delete(ctx context.Context, key string) error {
+	err := g.object(key).Delete(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil
+	}
+	return err
+}
+
+func (g *GCS) List(ctx context.Context) ([]string, error) {
+	keys := []string{}
+	it := g.bucketHandle().Objects(ctx, nil)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		keys = append(keys, attrs.Name)
+	}
+	return keys, nil
+}
+
+// gcsFactory implements BackendFactory for `gs://` connection strings, e.g.
+// `gs://bucket?credentials=/path/sa.json&project=my-project`.
+type gcsFactory struct{}
+
+func (gcsFactory) Scheme() string { return "gs" }
+
+func (gcsFactory) Open(u *url.URL) (ObjectStorage, error) {
+	bucket := u.Host
+	query := u.Query()
+	return NewGCS(query.Get("credentials"), bucket, query.Get("project"), query.Get("endpoint"))
+}
@@ -0,0 +1,144 @@
+package persistent
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// dekSize is the size, in bytes, of the filesystem's data-encryption key.
+const dekSize = 32
+
+// KeyProvider wraps and unwraps the filesystem's data-encryption key (DEK)
+// under a separate key-encryption key (KEK), so the DEK can be stored on
+// disk without the KEK ever touching it directly. This is the envelope
+// encryption pattern: WithEncryption holds the plaintext DEK in memory, but
+// only the wrapped DEK is ever written to the keyfile.
+type KeyProvider interface {
+	// WrapDEK encrypts dek under the provider's KEK.
+	WrapDEK(dek []byte) ([]byte, error)
+	// UnwrapDEK decrypts a DEK previously returned by WrapDEK.
+	UnwrapDEK(wrapped []byte) ([]byte, error)
+}
+
+// NewKeyProvider constructs a KeyProvider from a connection string. Recognized
+// schemes are kms://aws/<key-arn>, kms://gcp/<resource-name>,
+// kms://vault/<key-name>, and passphrase://, which wraps the DEK with a key
+// derived from password instead of a KMS. uri is mandatory; config.go only
+// calls this when a key-provider has been explicitly configured, and
+// otherwise uses the original, non-envelope password-based encryption path.
+func NewKeyProvider(uri, password string) (KeyProvider, error) {
+	if uri == "" {
+		return nil, fmt.Errorf("no key provider given for encryption")
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("parsing key provider url: %v", err)
+	}
+
+	if u.Scheme == "passphrase" {
+		if password == "" {
+			return nil, fmt.Errorf("no password given for passphrase key provider")
+		}
+		return newPassphraseKeyProvider(password), nil
+	} else if u.Scheme != "kms" {
+		return nil, fmt.Errorf("unrecognized key provider scheme %q", u.Scheme)
+	}
+
+	backend, id := u.Host, strings.TrimPrefix(u.Path, "/")
+	switch backend {
+	case "aws":
+		return newAWSKMSKeyProvider(id), nil
+	case "gcp":
+		return newGCPKMSKeyProvider(id), nil
+	case "vault":
+		return newVaultKeyProvider(id), nil
+	default:
+		return nil, fmt.Errorf("unrecognized kms backend %q", backend)
+	}
+}
+
+// GenerateDEK returns a new, random data-encryption key.
+func GenerateDEK() ([]byte, error) {
+	dek := make([]byte, dekSize)
+	if _, err := io.ReadFull(rand.Reader, dek); err != nil {
+		return nil, err
+	}
+	return dek, nil
+}
+
+// passphraseKeyProvider wraps the DEK with a key derived from a user
+// passphrase via scrypt, matching utahfs's original non-envelope behavior
+// except that the derived key now wraps a random DEK instead of being used
+// directly.
+type passphraseKeyProvider struct {
+	password string
+}
+
+func newPassphraseKeyProvider(password string) KeyProvider {
+	return &passphraseKeyProvider{password: password}
+}
+
+// passphraseSalt is fixed rather than random so that the same password
+// always derives the same KEK, without needing to persist a salt alongside
+// the keyfile.
+var passphraseSalt = []byte("github.com/Bren2010/utahfs/persistent/key_provider")
+
+func (p *passphraseKeyProvider) kek() ([]byte, error) {
+	return scrypt.Key([]byte(p.password), passphraseSalt, 1<<15, 8, 1, 32)
+}
+
+func (p *passphraseKeyProvider) WrapDEK(dek []byte) ([]byte, error) {
+	kek, err := p.kek()
+	if err != nil {
+		return nil, err
+	}
+	return aesGCMSeal(kek, dek)
+}
+
+func (p *passphraseKeyProvider) UnwrapDEK(wrapped []byte) ([]byte, error) {
+	kek, err := p.kek()
+	if err != nil {
+		return nil, err
+	}
+	return aesGCMOpen(kek, wrapped)
+}
+
+func aesGCMSeal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func aesGCMOpen(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
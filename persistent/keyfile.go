@@ -0,0 +1,71 @@
+package persistent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// keyfile is the on-disk representation of keyfile.json: the filesystem's
+// data-encryption key, wrapped under a key-encryption key managed by a
+// KeyProvider.
+type keyfile struct {
+	WrappedDEK []byte `json:"wrapped_dek"`
+}
+
+// LoadDEK returns the filesystem's data-encryption key, unwrapping it with
+// kp. If keyfilePath doesn't exist yet, a new DEK is generated, wrapped
+// under kp, and written there.
+func LoadDEK(kp KeyProvider, keyfilePath string) ([]byte, error) {
+	raw, err := ioutil.ReadFile(keyfilePath)
+	if os.IsNotExist(err) {
+		dek, err := GenerateDEK()
+		if err != nil {
+			return nil, err
+		}
+		if err := writeKeyfile(kp, keyfilePath, dek); err != nil {
+			return nil, err
+		}
+		return dek, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	kf := &keyfile{}
+	if err := json.Unmarshal(raw, kf); err != nil {
+		return nil, fmt.Errorf("parsing keyfile: %v", err)
+	}
+	return kp.UnwrapDEK(kf.WrappedDEK)
+}
+
+// RotateKEK re-wraps the filesystem's data-encryption key under newKP,
+// without touching the DEK itself or any data encrypted with it.
+func RotateKEK(oldKP, newKP KeyProvider, keyfilePath string) error {
+	raw, err := ioutil.ReadFile(keyfilePath)
+	if err != nil {
+		return err
+	}
+	kf := &keyfile{}
+	if err := json.Unmarshal(raw, kf); err != nil {
+		return fmt.Errorf("parsing keyfile: %v", err)
+	}
+
+	dek, err := oldKP.UnwrapDEK(kf.WrappedDEK)
+	if err != nil {
+		return fmt.Errorf("unwrapping dek: %v", err)
+	}
+	return writeKeyfile(newKP, keyfilePath, dek)
+}
+
+func writeKeyfile(kp KeyProvider, keyfilePath string, dek []byte) error {
+	wrapped, err := kp.WrapDEK(dek)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(&keyfile{WrappedDEK: wrapped})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(keyfilePath, raw, 0600)
+}
@@ -0,0 +1,55 @@
+package persistent
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// awsKMSKeyProvider wraps the DEK using an AWS KMS customer master key,
+// identified by ARN.
+type awsKMSKeyProvider struct {
+	keyArn string
+}
+
+func newAWSKMSKeyProvider(keyArn string) KeyProvider {
+	return &awsKMSKeyProvider{keyArn: keyArn}
+}
+
+func (p *awsKMSKeyProvider) client() (*kms.KMS, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	return kms.New(sess), nil
+}
+
+func (p *awsKMSKeyProvider) WrapDEK(dek []byte) ([]byte, error) {
+	client, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.Encrypt(&kms.EncryptInput{
+		KeyId:     aws.String(p.keyArn),
+		Plaintext: dek,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+func (p *awsKMSKeyProvider) UnwrapDEK(wrapped []byte) ([]byte, error) {
+	client, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.Decrypt(&kms.DecryptInput{
+		KeyId:          aws.String(p.keyArn),
+		CiphertextBlob: wrapped,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
@@ -0,0 +1,55 @@
+package persistent
+
+import (
+	"context"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
+)
+
+// gcpKMSKeyProvider wraps the DEK using a GCP Cloud KMS key, identified by
+// its full resource name, e.g.
+// "projects/p/locations/global/keyRings/r/cryptoKeys/k".
+type gcpKMSKeyProvider struct {
+	resourceName string
+}
+
+func newGCPKMSKeyProvider(resourceName string) KeyProvider {
+	return &gcpKMSKeyProvider{resourceName: resourceName}
+}
+
+func (p *gcpKMSKeyProvider) WrapDEK(dek []byte) ([]byte, error) {
+	ctx := context.Background()
+	client, err := kms.NewKeyManagementClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	resp, err := client.Encrypt(ctx, &kmspb.EncryptRequest{
+		Name:      p.resourceName,
+		Plaintext: dek,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Ciphertext, nil
+}
+
+func (p *gcpKMSKeyProvider) UnwrapDEK(wrapped []byte) ([]byte, error) {
+	ctx := context.Background()
+	client, err := kms.NewKeyManagementClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	resp, err := client.Decrypt(ctx, &kmspb.DecryptRequest{
+		Name:       p.resourceName,
+		Ciphertext: wrapped,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
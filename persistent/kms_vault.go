@@ -0,0 +1,64 @@
+package persistent
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// vaultKeyProvider wraps the DEK using a HashiCorp Vault transit engine key,
+// identified by its key name (e.g. "utahfs"). Vault's address and auth
+// token are taken from the standard VAULT_ADDR/VAULT_TOKEN environment
+// variables.
+type vaultKeyProvider struct {
+	keyName string
+}
+
+func newVaultKeyProvider(keyName string) KeyProvider {
+	return &vaultKeyProvider{keyName: keyName}
+}
+
+func (p *vaultKeyProvider) client() (*vault.Logical, error) {
+	client, err := vault.NewClient(vault.DefaultConfig())
+	if err != nil {
+		return nil, err
+	}
+	return client.Logical(), nil
+}
+
+func (p *vaultKeyProvider) WrapDEK(dek []byte) ([]byte, error) {
+	logical, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := logical.Write(fmt.Sprintf("transit/encrypt/%s", p.keyName), map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(dek),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, ok := secret.Data["ciphertext"].(string)
+	if !ok {
+		return nil, fmt.Errorf("vault: response missing ciphertext")
+	}
+	return []byte(ciphertext), nil
+}
+
+func (p *vaultKeyProvider) UnwrapDEK(wrapped []byte) ([]byte, error) {
+	logical, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := logical.Write(fmt.Sprintf("transit/decrypt/%s", p.keyName), map[string]interface{}{
+		"ciphertext": string(wrapped),
+	})
+	if err != nil {
+		return nil, err
+	}
+	plaintextB64, ok := secret.Data["plaintext"].(string)
+	if !ok {
+		return nil, fmt.Errorf("vault: response missing plaintext")
+	}
+	return base64.StdEncoding.DecodeString(plaintextB64)
+}
@@ -0,0 +1,86 @@
+package persistent
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+func init() {
+	RegisterBackend(localFactory{})
+}
+
+// Local implements the ObjectStorage interface by storing each object as a
+// file in a directory on disk. It's mainly useful for local testing and for
+// `file://` destinations such as a mounted network drive.
+type Local struct {
+	dir string
+}
+
+// NewLocal returns a Local-backed ObjectStorage rooted at dir, which is
+// created if it doesn't already exist.
+func NewLocal(dir string) (ObjectStorage, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Local{dir: dir}, nil
+}
+
+func (l *Local) path(key string) string {
+	return filepath.Join(l.dir, url.PathEscape(key))
+}
+
+func (l *Local) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := ioutil.ReadFile(l.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	}
+	return data, err
+}
+
+func (l *Local) Set(ctx context.Context, key string, data []byte) error {
+	return ioutil.WriteFile(l.path(key), data, 0600)
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	err := os.Remove(l.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (l *Local) List(ctx context.Context) ([]string, error) {
+	entries, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		key, err := url.PathUnescape(entry.Name())
+		if err != nil {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// localFactory implements BackendFactory for `file://` connection strings,
+// e.g. `file:///var/lib/utahfs`.
+type localFactory struct{}
+
+func (localFactory) Scheme() string { return "file" }
+
+func (localFactory) Open(u *url.URL) (ObjectStorage, error) {
+	dir := u.Path
+	if dir == "" {
+		dir = u.Opaque
+	}
+	return NewLocal(dir)
+}
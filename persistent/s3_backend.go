@@ -0,0 +1,26 @@
+package persistent
+
+import (
+	"net/url"
+	"strings"
+)
+
+func init() {
+	RegisterBackend(s3Factory{})
+}
+
+// s3Factory implements BackendFactory for `s3://` connection strings, e.g.
+// `s3://key:secret@host/bucket?region=us-east-1`.
+type s3Factory struct{}
+
+func (s3Factory) Scheme() string { return "s3" }
+
+func (s3Factory) Open(u *url.URL) (ObjectStorage, error) {
+	appId := u.User.Username()
+	appKey, _ := u.User.Password()
+	host := u.Host
+	bucket := strings.TrimPrefix(u.Path, "/")
+	region := u.Query().Get("region")
+
+	return NewS3(appId, appKey, bucket, host, region)
+}